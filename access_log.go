@@ -0,0 +1,131 @@
+package nghttp
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nickelghost/ngtel"
+)
+
+// AccessLogFormat selects the output format used by UseAccessLog.
+type AccessLogFormat int
+
+const (
+	// AccessLogFormatCommon writes entries in the Apache Common Log Format.
+	AccessLogFormatCommon AccessLogFormat = iota
+
+	// AccessLogFormatCombined writes entries in the Combined Log Format,
+	// which extends Common with the Referer and User-Agent headers.
+	AccessLogFormatCombined
+
+	// AccessLogFormatJSON writes entries as structured slog JSON.
+	AccessLogFormatJSON
+)
+
+// AccessLogOptions configures UseAccessLogWithOptions.
+type AccessLogOptions struct {
+	// Format selects the output format. Defaults to AccessLogFormatCommon.
+	Format AccessLogFormat
+
+	// OnComplete, when set, is called after each request completes with the
+	// response status, bytes written, and duration, for exporting metrics.
+	OnComplete func(r *http.Request, status int, bytes int64, dur time.Duration)
+}
+
+// UseAccessLog is a middleware that logs one access-log entry per request in
+// the given format. Every format records the remote address, method, path,
+// status code, and bytes written; Combined adds the referer and user-agent,
+// and JSON records all of these plus the duration.
+func UseAccessLog(format AccessLogFormat, next http.Handler) http.Handler {
+	return UseAccessLogWithOptions(AccessLogOptions{Format: format}, next)
+}
+
+// UseAccessLogWithOptions is like UseAccessLog but also accepts an
+// OnComplete hook for exporting metrics alongside the log line.
+func UseAccessLogWithOptions(opts AccessLogOptions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		start := time.Now()
+
+		next.ServeHTTP(rw, r)
+
+		dur := time.Since(start)
+
+		switch opts.Format {
+		case AccessLogFormatCombined:
+			slog.Info(combinedLogLine(r, rw.statusCode, rw.bytesWritten))
+		case AccessLogFormatJSON:
+			logAccessJSON(r, rw.statusCode, rw.bytesWritten, dur)
+		case AccessLogFormatCommon:
+			fallthrough
+		default:
+			slog.Info(commonLogLine(r, rw.statusCode, rw.bytesWritten))
+		}
+
+		if opts.OnComplete != nil {
+			opts.OnComplete(r, rw.statusCode, rw.bytesWritten, dur)
+		}
+	})
+}
+
+// logAccessJSON logs a structured slog entry for a completed request,
+// enriched with the request ID and trace path like the rest of the package.
+func logAccessJSON(r *http.Request, status int, bytes int64, dur time.Duration) {
+	ctx := r.Context()
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+
+	slog.Info(
+		"Request completed",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", status,
+		"bytes", bytes,
+		"duration", dur,
+		"remoteAddr", remoteHost(r),
+		"referer", r.Referer(),
+		"userAgent", r.UserAgent(),
+		"requestID", requestID,
+		"trace", ngtel.GetCloudTracePath(ctx),
+	)
+}
+
+// commonLogLine formats r and its outcome per the Apache Common Log Format:
+// %h %l %u %t "%r" %>s %b. The %t field holds the time the line is formatted,
+// after the response has completed, not the time the request was received.
+func commonLogLine(r *http.Request, status int, bytes int64) string {
+	return fmt.Sprintf(
+		`%s - - [%s] "%s %s %s" %d %s`,
+		remoteHost(r), time.Now().Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, r.URL.RequestURI(), r.Proto, status, byteCount(bytes),
+	)
+}
+
+// combinedLogLine formats r and its outcome per the Combined Log Format,
+// which appends the Referer and User-Agent headers to the Common Log Format.
+func combinedLogLine(r *http.Request, status int, bytes int64) string {
+	return fmt.Sprintf(`%s "%s" "%s"`, commonLogLine(r, status, bytes), r.Referer(), r.UserAgent())
+}
+
+// byteCount formats a body size the way Apache does: a dash for zero bytes.
+func byteCount(bytes int64) string {
+	if bytes == 0 {
+		return "-"
+	}
+
+	return strconv.FormatInt(bytes, 10)
+}
+
+// remoteHost returns the client host portion of r.RemoteAddr, falling back
+// to the raw value if it cannot be split into host and port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
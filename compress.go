@@ -0,0 +1,281 @@
+package nghttp
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported content-coding names, in the order they are preferred when a
+// client's Accept-Encoding does not express a clear quality preference.
+const (
+	encodingZstd    = "zstd"
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+var compressPreference = []string{encodingZstd, encodingGzip, encodingDeflate} //nolint:gochecknoglobals
+
+// compressibleSkipPrefixes lists Content-Type prefixes that are already
+// compressed (or otherwise not worth compressing) and so are passed through
+// unchanged even if the client accepts a content-coding.
+var compressibleSkipPrefixes = []string{"image/", "video/", "application/zip"} //nolint:gochecknoglobals
+
+var ( //nolint:gochecknoglobals
+	gzipPool = sync.Pool{New: func() any { w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression); return w }}
+
+	flatePool = sync.Pool{New: func() any { w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression); return w }}
+
+	zstdPool = sync.Pool{New: func() any {
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault))
+
+		return w
+	}}
+)
+
+// getEncoder fetches a pooled compressor for encoding, reset to write to w.
+func getEncoder(encoding string, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case encodingGzip:
+		enc, _ := gzipPool.Get().(*gzip.Writer)
+		enc.Reset(w)
+
+		return enc
+	case encodingDeflate:
+		enc, _ := flatePool.Get().(*flate.Writer)
+		enc.Reset(w)
+
+		return enc
+	case encodingZstd:
+		enc, _ := zstdPool.Get().(*zstd.Encoder)
+		enc.Reset(w)
+
+		return enc
+	default:
+		return nil
+	}
+}
+
+// putEncoder returns enc to its pool once the response has been closed.
+func putEncoder(encoding string, enc io.WriteCloser) {
+	switch encoding {
+	case encodingGzip:
+		gzipPool.Put(enc)
+	case encodingDeflate:
+		flatePool.Put(enc)
+	case encodingZstd:
+		zstdPool.Put(enc)
+	}
+}
+
+// negotiateEncoding parses an Accept-Encoding header and returns the
+// best-supported content-coding, or "" if none is acceptable. An encoding
+// explicitly sent with q=0 is never chosen, even if "*" would otherwise
+// accept it; among the rest, the highest q-value wins, ties broken by
+// compressPreference order.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	qs := map[string]float64{}
+
+	for _, part := range strings.Split(header, ",") {
+		name, q := parseEncodingQuality(part)
+		if name != "" {
+			qs[name] = q
+		}
+	}
+
+	wildcardQ, hasWildcard := qs["*"]
+
+	best, bestQ := "", -1.0
+
+	for _, pref := range compressPreference {
+		q, ok := qs[pref]
+		if !ok {
+			if !hasWildcard {
+				continue
+			}
+
+			q = wildcardQ
+		}
+
+		if q > 0 && q > bestQ {
+			best, bestQ = pref, q
+		}
+	}
+
+	return best
+}
+
+// parseEncodingQuality parses a single Accept-Encoding list element such as
+// "gzip;q=0.8" into its name and quality value, defaulting q to 1.
+func parseEncodingQuality(part string) (string, float64) {
+	fields := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(fields[0]))
+
+	q := 1.0
+
+	for _, param := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.TrimSpace(key) != "q" {
+			continue
+		}
+
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			q = parsed
+		}
+	}
+
+	return name, q
+}
+
+// isIncompressibleContentType reports whether ct is a content type that is
+// already compressed and therefore not worth compressing again.
+func isIncompressibleContentType(ct string) bool {
+	ct, _, _ = strings.Cut(ct, ";")
+	ct = strings.TrimSpace(ct)
+
+	for _, prefix := range compressibleSkipPrefixes {
+		if strings.HasPrefix(ct, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressWriter buffers up to minSize bytes of a response before deciding
+// whether to compress it, so small responses (for which compression would
+// add more overhead than it saves) are written through unchanged.
+type compressWriter struct {
+	*responseWriter
+
+	encoding   string
+	minSize    int
+	buf        []byte
+	enc        io.WriteCloser
+	headerCode int
+	decided    bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.headerCode == 0 {
+		cw.headerCode = code
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+
+		if len(cw.buf) < cw.minSize {
+			return len(b), nil
+		}
+
+		// decide flushes the buffer, which already contains b, so there is
+		// nothing left to write on this call.
+		cw.decide(true)
+
+		return len(b), nil
+	}
+
+	if cw.enc != nil {
+		if _, err := cw.enc.Write(b); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	return cw.responseWriter.Write(b)
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(len(cw.buf) >= cw.minSize)
+	}
+
+	if flusher, ok := cw.enc.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+
+	cw.responseWriter.Flush()
+}
+
+// decide picks whether to compress based on the buffered Content-Type and
+// whether the buffered body met minSize, writes the response headers, and
+// flushes any buffered bytes through the chosen path.
+func (cw *compressWriter) decide(meetsMinSize bool) {
+	cw.decided = true
+
+	if cw.headerCode == 0 {
+		cw.headerCode = http.StatusOK
+	}
+
+	header := cw.responseWriter.Header()
+	header.Add("Vary", "Accept-Encoding")
+
+	if meetsMinSize && cw.encoding != "" && !isIncompressibleContentType(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.enc = getEncoder(cw.encoding, cw.responseWriter)
+	}
+
+	cw.responseWriter.WriteHeader(cw.headerCode)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if len(buf) == 0 {
+		return
+	}
+
+	if cw.enc != nil {
+		_, _ = cw.enc.Write(buf)
+	} else {
+		_, _ = cw.responseWriter.Write(buf)
+	}
+}
+
+// close finalizes the response, deciding if it never crossed minSize, and
+// returns any active encoder to its pool.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		putEncoder(cw.encoding, cw.enc)
+	}
+}
+
+// UseCompress is a middleware that negotiates Accept-Encoding and compresses
+// responses of at least minSize bytes with gzip, deflate, or zstd, whichever
+// the client prefers and the server supports. Already-compressed content
+// types (image/*, video/*, application/zip) are left untouched. It integrates
+// with the existing Respond path, so JSON payloads over the threshold are
+// compressed automatically.
+func UseCompress(minSize int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+		cw := &compressWriter{
+			responseWriter: newResponseWriter(w),
+			encoding:       encoding,
+			minSize:        minSize,
+		}
+
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
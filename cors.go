@@ -0,0 +1,231 @@
+package nghttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsVaryHeader is the Vary value applied to preflight responses so that
+// caches correctly key on the request's CORS-relevant headers. Actual
+// cross-origin responses vary on Origin only.
+const corsVaryHeader = "Origin, Access-Control-Request-Method, Access-Control-Request-Headers"
+
+// CORSOptions configures the middleware returned by NewCORS. The zero value
+// allows no origins; at least one of AllowOrigins or AllowOriginFunc must be
+// set for any cross-origin request to succeed.
+type CORSOptions struct {
+	// AllowOrigins is a list of allowed origins. An entry may contain a
+	// single leading wildcard segment, e.g. "*.example.com", to match any
+	// subdomain. Use "*" to allow any origin.
+	AllowOrigins []string
+
+	// AllowOriginFunc, when set, is consulted for every origin not matched
+	// by AllowOrigins. Returning true allows the origin.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowMethods lists the HTTP methods allowed for cross-origin requests.
+	AllowMethods []string
+
+	// AllowHeaders lists the request headers allowed for cross-origin
+	// requests. Matching against Access-Control-Request-Headers is
+	// case-insensitive, per the Fetch spec.
+	AllowHeaders []string
+
+	// ExposedHeaders lists the response headers browsers are allowed to
+	// expose to scripts via Access-Control-Expose-Headers.
+	ExposedHeaders []string
+
+	// AllowCredentials sets Access-Control-Allow-Credentials. When true, an
+	// AllowOrigins entry of "*" echoes back the actual request origin instead
+	// of "*", since the Fetch spec forbids a wildcard with credentials.
+	AllowCredentials bool
+
+	// MaxAge sets how long a preflight response may be cached via
+	// Access-Control-Max-Age. Zero omits the header.
+	MaxAge time.Duration
+
+	// AllowPrivateNetwork, when true, grants requests carrying
+	// Access-Control-Request-Private-Network by responding with
+	// Access-Control-Allow-Private-Network on preflight.
+	AllowPrivateNetwork bool
+}
+
+// NewCORS builds a CORS middleware from opts. It distinguishes preflight
+// requests (OPTIONS with an Access-Control-Request-Method header) from actual
+// cross-origin requests, short-circuiting preflights with a 204 and only the
+// headers required by the spec, and otherwise decorates the response with
+// Access-Control-Allow-Origin/Credentials/Expose-Headers before calling next.
+func NewCORS(opts CORSOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			allowedOrigin, ok := opts.resolveOrigin(origin)
+			if !ok {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				opts.handlePreflight(w, r, allowedOrigin)
+
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+
+			if opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if len(opts.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// handlePreflight validates the requested method/headers against opts and
+// writes a 204 with only the headers required to answer the preflight.
+func (opts CORSOptions) handlePreflight(w http.ResponseWriter, r *http.Request, allowedOrigin string) {
+	h := w.Header()
+	h.Add("Vary", corsVaryHeader)
+
+	requestedMethod := r.Header.Get("Access-Control-Request-Method")
+	if !containsFold(opts.AllowMethods, requestedMethod) {
+		w.WriteHeader(http.StatusForbidden)
+
+		return
+	}
+
+	if requestedHeaders := r.Header.Get("Access-Control-Request-Headers"); requestedHeaders != "" {
+		for _, header := range strings.Split(requestedHeaders, ",") {
+			if !containsFold(opts.AllowHeaders, strings.TrimSpace(header)) {
+				w.WriteHeader(http.StatusForbidden)
+
+				return
+			}
+		}
+
+		h.Set("Access-Control-Allow-Headers", requestedHeaders)
+	}
+
+	h.Set("Access-Control-Allow-Origin", allowedOrigin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(opts.AllowMethods, ", "))
+
+	if opts.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if opts.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+	}
+
+	if opts.AllowPrivateNetwork && r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+		h.Set("Access-Control-Allow-Private-Network", "true")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveOrigin returns the value to echo back in Access-Control-Allow-Origin
+// for the given request origin, and whether the origin is allowed at all.
+func (opts CORSOptions) resolveOrigin(origin string) (string, bool) {
+	for _, allowed := range opts.AllowOrigins {
+		if allowed == "*" {
+			if opts.AllowCredentials {
+				return origin, true
+			}
+
+			return "*", true
+		}
+
+		if matchOrigin(allowed, origin) {
+			return origin, true
+		}
+	}
+
+	if opts.AllowOriginFunc != nil && opts.AllowOriginFunc(origin) {
+		return origin, true
+	}
+
+	return "", false
+}
+
+// matchOrigin reports whether origin matches pattern, where pattern may
+// contain a single leading "*" wildcard segment, e.g. "*.example.com".
+func matchOrigin(pattern, origin string) bool {
+	suffix, ok := strings.CutPrefix(pattern, "*")
+	if !ok {
+		return pattern == origin
+	}
+
+	return strings.HasSuffix(origin, suffix)
+}
+
+// containsFold reports whether values contains s under case-insensitive
+// comparison.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CORSPolicy is a per-route set of CORS rules, as registered with
+// UseCORSPerPath.
+type CORSPolicy struct {
+	Origins     []string
+	Headers     []string
+	Methods     []string
+	Credentials bool
+	MaxAge      time.Duration
+}
+
+// options converts p to the equivalent CORSOptions for NewCORS.
+func (p CORSPolicy) options() CORSOptions {
+	return CORSOptions{
+		AllowOrigins:     p.Origins,
+		AllowHeaders:     p.Headers,
+		AllowMethods:     p.Methods,
+		AllowCredentials: p.Credentials,
+		MaxAge:           p.MaxAge,
+	}
+}
+
+// UseCORSPerPath builds a middleware that applies a different CORSPolicy
+// depending on the request path, using net/http's 1.22 ServeMux pattern
+// syntax (e.g. "/.well-known/", "GET /authorize") as the policies map's keys.
+// This lets a discovery endpoint stay world-readable while other endpoints
+// are locked to a specific origin list, matching the two-tier CORS pattern
+// common in OIDC/OAuth servers. A policy registered under "/" acts as the
+// default: net/http's pattern matching only falls back to it when no more
+// specific pattern matches, so it's safe to register narrower patterns
+// alongside it without the default ever shadowing them.
+func UseCORSPerPath(policies map[string]CORSPolicy, next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+
+	for pattern, policy := range policies {
+		mux.Handle(pattern, NewCORS(policy.options())(next))
+	}
+
+	if _, ok := policies["/"]; !ok {
+		mux.Handle("/", next)
+	}
+
+	return mux
+}
@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,13 +28,14 @@ type GenericResponse struct {
 }
 
 // Respond is a utility function to send a JSON response with a specific HTTP
-// status code. It logs the request ID and trace path, and handles different
-// levels of errors based on the status code.
+// status code. It logs the request ID, client IP, and trace path, and handles
+// different levels of errors based on the status code.
 func Respond(w http.ResponseWriter, r *http.Request, code int, err error, res any) {
 	ctx := r.Context()
 	requestID, _ := ctx.Value(RequestIDKey).(string)
+	clientIP, _ := ctx.Value(ClientIPKey).(string)
 	statusText := http.StatusText(code)
-	logger := slog.With("requestID", requestID, "trace", ngtel.GetCloudTracePath(ctx))
+	logger := slog.With("requestID", requestID, "clientIP", clientIP, "trace", ngtel.GetCloudTracePath(ctx))
 
 	switch {
 	case code >= http.StatusInternalServerError:
@@ -113,35 +113,3 @@ func UseRequestLogging(next http.Handler) http.Handler {
 		)
 	})
 }
-
-// UseCORS is a middleware that adds CORS headers to the HTTP response. It checks
-// the request's Origin header against a list of allowed origins and sets the
-// Access-Control-Allow-Origin header accordingly. It also sets the allowed
-// headers and methods for CORS requests. If the request method is OPTIONS, it
-// responds with a 200 OK status and the appropriate CORS headers without
-// processing the request further. For other methods, it calls the next handler
-// in the chain.
-func UseCORS(
-	allowedOrigins []string, allowedHeaders []string, allowedMethods []string, next http.Handler,
-) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		origin := r.Header.Get("Origin")
-
-		for _, allowedOrigin := range allowedOrigins {
-			if allowedOrigin == origin {
-				w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
-			}
-		}
-
-		w.Header().Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
-		w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
-
-		if r.Method == http.MethodOptions {
-			RespondGeneric(w, r, http.StatusOK, nil)
-
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
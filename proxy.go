@@ -0,0 +1,167 @@
+package nghttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPKey is the key used to store the resolved client IP in the request
+// context. UseProxyHeaders sets it on every request, trusted or not, so that
+// Respond's logger can include it alongside requestID and trace.
+const ClientIPKey = "clientIP"
+
+// UseProxyHeaders is a middleware that resolves the real client IP, scheme,
+// and host from proxy headers, but only when the immediate peer
+// (r.RemoteAddr) matches one of trustedProxies. This guards against a client
+// spoofing Forwarded/X-Forwarded-*/X-Real-IP headers to impersonate another
+// address: those headers are only honored when they arrive via a proxy the
+// operator has configured to strip and regenerate them. Forwarded (RFC 7239)
+// takes priority over the older X-Forwarded-For/-Proto and X-Real-IP headers
+// when both are present. The resolved client IP is always stored under
+// ClientIPKey, whether or not the peer was trusted.
+func UseProxyHeaders(trustedProxies []netip.Prefix, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := remoteAddrHost(r.RemoteAddr)
+
+		if isTrustedProxy(r.RemoteAddr, trustedProxies) {
+			if ip, scheme, host, ok := resolveForwarded(r); ok {
+				clientIP = ip
+				r.RemoteAddr = net.JoinHostPort(ip, "0")
+
+				if scheme != "" {
+					r.URL.Scheme = scheme
+				}
+
+				if host != "" {
+					r.Host = host
+				}
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), ClientIPKey, clientIP) //nolint:revive,staticcheck
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isTrustedProxy reports whether remoteAddr's host falls within trustedProxies.
+func isTrustedProxy(remoteAddr string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(remoteAddrHost(remoteAddr))
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveForwarded extracts the client IP, scheme, and host from the
+// Forwarded header, falling back to X-Forwarded-For, X-Forwarded-Proto, and
+// X-Real-IP. ok is false if none of those headers yielded a usable IP.
+func resolveForwarded(r *http.Request) (ip, scheme, host string, ok bool) {
+	if forwarded := r.Header.Get("Forwarded"); forwarded != "" {
+		ip, scheme, host = parseForwarded(forwarded)
+	}
+
+	if ip == "" {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			ip = strings.TrimSpace(first)
+		}
+	}
+
+	if ip == "" {
+		ip = strings.TrimSpace(r.Header.Get("X-Real-IP"))
+	}
+
+	if scheme == "" {
+		scheme = r.Header.Get("X-Forwarded-Proto")
+	}
+
+	if ip == "" {
+		return "", "", "", false
+	}
+
+	return ip, scheme, host, true
+}
+
+// parseForwarded extracts the for, proto, and host parameters from the first
+// element of a Forwarded header (RFC 7239). Only the first element is
+// considered: the one nearest the original client, added by the first proxy
+// on the path (or sent by the client itself).
+func parseForwarded(header string) (ip, scheme, host string) {
+	first, _, _ := strings.Cut(header, ",")
+
+	for _, pair := range strings.Split(first, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "for":
+			ip = trimForwardedNodeIdentifier(value)
+		case "proto":
+			scheme = value
+		case "host":
+			host = value
+		}
+	}
+
+	return ip, scheme, host
+}
+
+// trimForwardedNodeIdentifier strips an optional port (and IPv6 brackets)
+// from a Forwarded "for" node identifier, e.g. `"[2001:db8::1]:8080"`.
+func trimForwardedNodeIdentifier(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+
+	return strings.Trim(node, "[]")
+}
+
+// remoteAddrHost returns the host portion of a RemoteAddr-style host:port
+// string, or the original value if it has no port.
+func remoteAddrHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
+}
+
+// UseCanonicalHost is a middleware that redirects requests whose Host header
+// doesn't match target to the same path and query on target, using code as
+// the redirect status (typically http.StatusMovedPermanently or
+// http.StatusPermanentRedirect, depending on whether the method and body
+// should be preserved).
+func UseCanonicalHost(target string, code int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == target {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		scheme := "https"
+		if r.URL.Scheme != "" {
+			scheme = r.URL.Scheme
+		}
+
+		url := scheme + "://" + target + r.URL.RequestURI()
+
+		http.Redirect(w, r, url, code)
+	})
+}
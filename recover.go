@@ -0,0 +1,72 @@
+package nghttp
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/nickelghost/ngtel"
+)
+
+// RecoverOptions configures UseRecoverWithOptions. The zero value responds
+// with a generic 500 and omits the stack trace from the log entry.
+type RecoverOptions struct {
+	// PanicHandler, when set, is called instead of the default RespondGeneric
+	// call once the panic has been recovered and logged. It is responsible
+	// for writing a response; it is not called if headers have already been
+	// written by the time the panic occurred.
+	PanicHandler func(w http.ResponseWriter, r *http.Request, v any)
+
+	// PrintStack includes the recovered stack trace in the log entry.
+	PrintStack bool
+}
+
+// UseRecover is a middleware that recovers panics from next, logs the panic
+// value and full stack with the request ID and trace path, and responds with
+// a 500 via RespondGeneric. If a partial response has already been written
+// when the panic occurs, it does not attempt to write another one, since
+// doing so would only produce a "superfluous response.WriteHeader" warning on
+// an already-broken connection.
+func UseRecover(next http.Handler) http.Handler {
+	return UseRecoverWithOptions(RecoverOptions{PrintStack: true}, next)
+}
+
+// UseRecoverWithOptions is like UseRecover but allows overriding the panic
+// response via opts.PanicHandler and toggling the stack trace in the log.
+func UseRecoverWithOptions(opts RecoverOptions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			ctx := r.Context()
+			requestID, _ := ctx.Value(RequestIDKey).(string)
+			logger := slog.With("requestID", requestID, "trace", ngtel.GetCloudTracePath(ctx))
+
+			if opts.PrintStack {
+				logger.Error("panic recovered", "panic", v, "stack", string(debug.Stack()))
+			} else {
+				logger.Error("panic recovered", "panic", v)
+			}
+
+			if rw.wroteHeader {
+				return
+			}
+
+			if opts.PanicHandler != nil {
+				opts.PanicHandler(rw, r, v)
+
+				return
+			}
+
+			RespondGeneric(rw, r, http.StatusInternalServerError, fmt.Errorf("panic: %v", v))
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,88 @@
+package nghttp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps http.ResponseWriter to track whether headers have
+// already been written, which status code was sent, and how many bytes have
+// been written to the body, so middleware further up the chain (e.g.
+// UseRecover, UseAccessLog) can inspect the outcome of the request. It passes
+// through http.Hijacker, http.Flusher, and http.Pusher so streaming and
+// websocket handlers keep working when wrapped.
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	bytesWritten int64
+	wroteHeader  bool
+}
+
+// newResponseWriter wraps w, defaulting statusCode to http.StatusOK to match
+// the behavior of net/http when WriteHeader is never called explicitly.
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+// WriteHeader records the status code and marks headers as written before
+// delegating to the underlying ResponseWriter.
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+
+	rw.statusCode = code
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write marks headers as written, since net/http implicitly calls
+// WriteHeader(http.StatusOK) on the first Write if it hasn't been called yet,
+// and records the number of bytes written to the body.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+
+	return n, err
+}
+
+// Unwrap exposes the wrapped ResponseWriter to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack implements http.Hijacker by delegating to the wrapped
+// ResponseWriter, if it supports hijacking.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("nghttp: underlying ResponseWriter does not support hijacking")
+	}
+
+	return hijacker.Hijack()
+}
+
+// Flush implements http.Flusher by delegating to the wrapped ResponseWriter,
+// if it supports flushing.
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Push implements http.Pusher by delegating to the wrapped ResponseWriter, if
+// it supports server push.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := rw.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+
+	return pusher.Push(target, opts)
+}